@@ -0,0 +1,403 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/colin-404/logx"
+	"github.com/rs/xid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Chunked upload configuration knobs
+var (
+	MaxDataChunkSize  int64 = 4 << 20 // 4MiB per chunk
+	MaxInflightChunks       = 8       // concurrent GridFS writes allowed across all sessions
+	DefaultUploadTTL        = 24 * time.Hour
+	sniffSize               = 512
+)
+
+// gridFSBucketName must match the name GridFSBucket was created with (the
+// mongo-driver gridfs default, "fs"). PutChunk/CompleteUpload write directly
+// into its backing collections instead of going through gridfs.Bucket, since
+// a bucket upload stream owns its own chunk-index counter and can only
+// insert its fs.files document once per stream.
+const gridFSBucketName = "fs"
+
+// Error definitions
+var (
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+	ErrUploadSessionExpired  = errors.New("upload session expired")
+	ErrChunkOutOfOrder       = errors.New("chunk does not continue from last received offset")
+	ErrUploadIncomplete      = errors.New("upload session is missing chunks")
+	ErrInvalidChunkSize      = errors.New("chunk size does not match MaxDataChunkSize boundary")
+)
+
+// chunkBitmap tracks which byte ranges of an upload have been received, one
+// bit per chunk index (chunk size is fixed per-session at MaxDataChunkSize).
+type chunkBitmap []byte
+
+func newChunkBitmap(chunkCount int) chunkBitmap {
+	return make(chunkBitmap, (chunkCount+7)/8)
+}
+
+func (b chunkBitmap) set(idx int) {
+	b[idx/8] |= 1 << uint(idx%8)
+}
+
+func (b chunkBitmap) isSet(idx int) bool {
+	return b[idx/8]&(1<<uint(idx%8)) != 0
+}
+
+func (b chunkBitmap) allSet(chunkCount int) bool {
+	for i := 0; i < chunkCount; i++ {
+		if !b.isSet(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// UploadSession tracks the state of a resumable chunked upload so it can be
+// completed by a different process than the one that started it.
+type UploadSession struct {
+	SessionID            string             `bson:"sessionID" json:"sessionID"`
+	Filename             string             `bson:"filename" json:"filename"`
+	ExpectedSize         int64              `bson:"expectedSize" json:"expectedSize"`
+	ReceivedChunks       chunkBitmap        `bson:"receivedChunks" json:"receivedChunks"`
+	ChunkCount           int                `bson:"chunkCount" json:"chunkCount"`
+	ReceivedBytes        int64              `bson:"receivedBytes" json:"receivedBytes"`
+	PartialChecksumState []byte             `bson:"partialChecksumState" json:"-"`
+	SniffBuffer          []byte             `bson:"sniffBuffer,omitempty" json:"-"`
+	GridFSID             primitive.ObjectID `bson:"gridfsID" json:"gridfsID"`
+	Tags                 []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	Metadata             map[string]any     `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	ExpiresAt            time.Time          `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt            time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// ChunkedUploadOptions configures a new chunked upload session.
+type ChunkedUploadOptions struct {
+	ExpectedSize int64
+	TTL          time.Duration
+	Tags         []string
+	Metadata     map[string]any
+}
+
+// StoreImageChunked starts a new resumable chunked upload and returns the
+// session that future PutChunk/CompleteUpload calls are addressed to.
+func (is *ImageStore) StoreImageChunked(ctx context.Context, filename string, opts ChunkedUploadOptions) (*UploadSession, error) {
+	if opts.ExpectedSize <= 0 {
+		return nil, fmt.Errorf("expectedSize must be positive")
+	}
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultUploadTTL
+	}
+
+	chunkCount := int((opts.ExpectedSize + MaxDataChunkSize - 1) / MaxDataChunkSize)
+	h := sha256.New()
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal checksum state: %w", err)
+	}
+
+	now := time.Now()
+	session := &UploadSession{
+		SessionID:            xid.New().String(),
+		Filename:             filename,
+		ExpectedSize:         opts.ExpectedSize,
+		ReceivedChunks:       newChunkBitmap(chunkCount),
+		ChunkCount:           chunkCount,
+		PartialChecksumState: state,
+		GridFSID:             primitive.NewObjectID(),
+		Tags:                 opts.Tags,
+		Metadata:             opts.Metadata,
+		ExpiresAt:            now.Add(ttl),
+		CreatedAt:            now,
+	}
+
+	if _, err := is.uploadCollection().InsertOne(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+
+	return session, nil
+}
+
+// PutChunk appends a single chunk to the session's GridFS blob. Chunks must
+// be supplied in order (offset must equal the bytes received so far). The
+// chunk is written straight into the bucket's fs.chunks collection under the
+// session's stable files_id, at index offset/MaxDataChunkSize; the fs.files
+// document is only created once, by CompleteUpload. Progress is advanced
+// with a compare-and-set on receivedBytes so two processes racing to resume
+// the same session can't both apply the same chunk to the checksum state.
+func (is *ImageStore) PutChunk(ctx context.Context, sessionID string, offset int64, data []byte) error {
+	select {
+	case is.inflightChunks <- struct{}{}:
+		defer func() { <-is.inflightChunks }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	session, err := is.getUploadSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if offset != session.ReceivedBytes {
+		return ErrChunkOutOfOrder
+	}
+
+	// Every chunk must land exactly on a MaxDataChunkSize boundary, except
+	// the final one which carries whatever remains: otherwise chunkIdx
+	// (offset/MaxDataChunkSize) can collide or leave gaps in fs.chunks, and
+	// CompleteUpload's fs.files document (chunkSize: MaxDataChunkSize)
+	// would no longer describe the data actually stored.
+	remaining := session.ExpectedSize - offset
+	wantSize := MaxDataChunkSize
+	if remaining < wantSize {
+		wantSize = remaining
+	}
+	if int64(len(data)) != wantSize {
+		return ErrInvalidChunkSize
+	}
+
+	chunkIdx := int(offset / MaxDataChunkSize)
+	chunksColl, err := GetCollection(gridFSBucketName + ".chunks")
+	if err != nil {
+		return fmt.Errorf("failed to get GridFS chunks collection: %w", err)
+	}
+	_, err = chunksColl.UpdateOne(ctx,
+		bson.M{"files_id": session.GridFSID, "n": chunkIdx},
+		bson.M{"$set": bson.M{"files_id": session.GridFSID, "n": chunkIdx, "data": primitive.Binary{Data: data}}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write chunk to GridFS: %w", err)
+	}
+
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(session.PartialChecksumState); err != nil {
+		return fmt.Errorf("failed to restore checksum state: %w", err)
+	}
+	if _, err := h.Write(data); err != nil {
+		return fmt.Errorf("failed to update checksum: %w", err)
+	}
+	newState, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal checksum state: %w", err)
+	}
+
+	newBitmap := append(chunkBitmap(nil), session.ReceivedChunks...)
+	newBitmap.set(chunkIdx)
+
+	sniff := session.SniffBuffer
+	if len(sniff) < sniffSize {
+		need := sniffSize - len(sniff)
+		if need > len(data) {
+			need = len(data)
+		}
+		sniff = append(append([]byte(nil), sniff...), data[:need]...)
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"receivedChunks":       []byte(newBitmap),
+			"receivedBytes":        offset + int64(len(data)),
+			"partialChecksumState": newState,
+			"sniffBuffer":          sniff,
+		},
+	}
+	result, err := is.uploadCollection().UpdateOne(ctx, bson.M{"sessionID": sessionID, "receivedBytes": offset}, update)
+	if err != nil {
+		return fmt.Errorf("failed to persist chunk progress: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		// Another call already advanced receivedBytes past offset; our
+		// update to the checksum state and bitmap must not apply twice.
+		return ErrChunkOutOfOrder
+	}
+
+	return nil
+}
+
+// CompleteUpload finalizes a chunked upload: it validates that every chunk
+// was received, computes the final checksum, detects the content type, dedupes
+// by checksum, and inserts the resulting image metadata.
+func (is *ImageStore) CompleteUpload(ctx context.Context, sessionID string) (*ImageMeta, error) {
+	session, err := is.getUploadSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !session.ReceivedChunks.allSet(session.ChunkCount) {
+		return nil, ErrUploadIncomplete
+	}
+
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(session.PartialChecksumState); err != nil {
+		return nil, fmt.Errorf("failed to restore checksum state: %w", err)
+	}
+	checksum := fmt.Sprintf("%x", h.Sum(nil))
+
+	contentType := http.DetectContentType(session.SniffBuffer)
+	if !isValidImageType(contentType) {
+		is.abortUpload(ctx, session)
+		return nil, ErrInvalidImageType
+	}
+
+	if existing, err := is.GetImageByChecksum(ctx, checksum); err == nil && existing != nil {
+		is.abortUpload(ctx, session)
+		return existing, ErrImageAlreadyExists
+	}
+
+	filesColl, err := GetCollection(gridFSBucketName + ".files")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GridFS files collection: %w", err)
+	}
+	_, err = filesColl.InsertOne(ctx, bson.M{
+		"_id":        session.GridFSID,
+		"length":     session.ReceivedBytes,
+		"chunkSize":  MaxDataChunkSize,
+		"uploadDate": time.Now(),
+		"filename":   session.Filename,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize GridFS file: %w", err)
+	}
+
+	now := time.Now()
+	imageMeta := ImageMeta{
+		ImageID:      xid.New().String(),
+		GridFSID:     session.GridFSID,
+		OriginalName: session.Filename,
+		ContentType:  contentType,
+		Size:         session.ReceivedBytes,
+		Checksum:     checksum,
+		Digest:       canonicalDigest(checksum),
+		Tags:         session.Tags,
+		Metadata:     session.Metadata,
+		SearchText:   buildSearchText(session.Filename, session.Metadata),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if _, err := is.metaCollection.InsertOne(ctx, imageMeta); err != nil {
+		// Metadata save failed after the fs.files doc was written: clean up
+		// the now-orphaned GridFS blob, same as storeBlob does on this path.
+		if delErr := is.bucket.Delete(session.GridFSID); delErr != nil {
+			logx.Errorf("Failed to clean up GridFS file %s after metadata save failure: %v", session.GridFSID.Hex(), delErr)
+		}
+		return nil, fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	if _, err := is.uploadCollection().DeleteOne(ctx, bson.M{"sessionID": sessionID}); err != nil {
+		logx.Errorf("Failed to clean up upload session %s: %v", sessionID, err)
+	}
+
+	logx.Infof("Successfully completed chunked upload: %s (size: %d bytes)", imageMeta.ImageID, imageMeta.Size)
+	return &imageMeta, nil
+}
+
+func (is *ImageStore) getUploadSession(ctx context.Context, sessionID string) (*UploadSession, error) {
+	var session UploadSession
+	err := is.uploadCollection().FindOne(ctx, bson.M{"sessionID": sessionID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUploadSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrUploadSessionExpired
+	}
+	return &session, nil
+}
+
+// abortUpload discards a session's partial chunks and its tracking document,
+// used when CompleteUpload rejects the finished upload.
+func (is *ImageStore) abortUpload(ctx context.Context, session *UploadSession) {
+	if err := is.deletePartialGridFSChunks(ctx, session.GridFSID); err != nil {
+		logx.Errorf("Failed to delete partial GridFS chunks for session %s: %v", session.SessionID, err)
+	}
+	if _, err := is.uploadCollection().DeleteOne(ctx, bson.M{"sessionID": session.SessionID}); err != nil {
+		logx.Errorf("Failed to delete upload session %s: %v", session.SessionID, err)
+	}
+}
+
+// deletePartialGridFSChunks removes every fs.chunks document written for
+// filesID directly, since an incomplete upload never gets an fs.files
+// document for gridfs.Bucket.Delete to key off of.
+func (is *ImageStore) deletePartialGridFSChunks(ctx context.Context, filesID primitive.ObjectID) error {
+	chunksColl, err := GetCollection(gridFSBucketName + ".chunks")
+	if err != nil {
+		return fmt.Errorf("failed to get GridFS chunks collection: %w", err)
+	}
+	if _, err := chunksColl.DeleteMany(ctx, bson.M{"files_id": filesID}); err != nil {
+		return fmt.Errorf("failed to delete GridFS chunks: %w", err)
+	}
+	return nil
+}
+
+func (is *ImageStore) uploadCollection() *mongo.Collection {
+	coll, err := GetCollection("imageUploads")
+	if err != nil {
+		logx.Errorf("Failed to get imageUploads collection: %v", err)
+		return nil
+	}
+	return coll
+}
+
+// StartUploadSweeper runs a background loop that deletes expired upload
+// sessions along with their partial GridFS files, until ctx is cancelled.
+func (is *ImageStore) StartUploadSweeper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				is.sweepExpiredUploads(ctx)
+			}
+		}
+	}()
+}
+
+func (is *ImageStore) sweepExpiredUploads(ctx context.Context) {
+	cursor, err := is.uploadCollection().Find(ctx, bson.M{"expiresAt": bson.M{"$lt": time.Now()}})
+	if err != nil {
+		logx.Errorf("Failed to list expired upload sessions: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var swept int
+	for cursor.Next(ctx) {
+		var session UploadSession
+		if err := cursor.Decode(&session); err != nil {
+			continue
+		}
+		if err := is.deletePartialGridFSChunks(ctx, session.GridFSID); err != nil {
+			logx.Errorf("Failed to delete partial GridFS chunks for session %s: %v", session.SessionID, err)
+		}
+		if _, err := is.uploadCollection().DeleteOne(ctx, bson.M{"sessionID": session.SessionID}); err != nil {
+			logx.Errorf("Failed to delete expired upload session %s: %v", session.SessionID, err)
+			continue
+		}
+		swept++
+	}
+
+	if swept > 0 {
+		logx.Infof("Swept %d expired upload session(s)", swept)
+	}
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"testing"
+)
+
+func TestChunkBitmap(t *testing.T) {
+	b := newChunkBitmap(3)
+	if b.allSet(3) {
+		t.Fatal("expected fresh bitmap to report not all set")
+	}
+
+	b.set(0)
+	b.set(2)
+	if !b.isSet(0) || b.isSet(1) || !b.isSet(2) {
+		t.Fatalf("unexpected bitmap state after set(0), set(2): %v", []byte(b))
+	}
+	if b.allSet(3) {
+		t.Fatal("expected bitmap to report not all set until chunk 1 is set")
+	}
+
+	b.set(1)
+	if !b.allSet(3) {
+		t.Fatal("expected bitmap to report all set once every chunk index is set")
+	}
+}
+
+// TestIncrementalChecksumMatchesOneShot verifies that restoring a sha256
+// hasher from PartialChecksumState between chunks and writing the next
+// chunk into it produces the same digest as hashing the whole payload in one
+// call. PutChunk/CompleteUpload rely on this to checksum an upload across
+// calls (and processes) without holding every chunk in memory at once.
+func TestIncrementalChecksumMatchesOneShot(t *testing.T) {
+	chunk1 := []byte("first chunk of data")
+	chunk2 := []byte("second chunk of data")
+
+	h := sha256.New()
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal initial checksum state: %v", err)
+	}
+
+	for _, chunk := range [][]byte{chunk1, chunk2} {
+		h := sha256.New()
+		if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			t.Fatalf("failed to restore checksum state: %v", err)
+		}
+		if _, err := h.Write(chunk); err != nil {
+			t.Fatalf("failed to write chunk: %v", err)
+		}
+		state, err = h.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal checksum state: %v", err)
+		}
+	}
+
+	final := sha256.New()
+	if err := final.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		t.Fatalf("failed to restore final checksum state: %v", err)
+	}
+	got := final.Sum(nil)
+
+	want := sha256.Sum256(append(append([]byte(nil), chunk1...), chunk2...))
+	if string(got) != string(want[:]) {
+		t.Fatalf("incremental checksum = %x, want %x", got, want)
+	}
+}
+
+func TestChunkIndexIsStablePerOffset(t *testing.T) {
+	MaxDataChunkSize = 4 << 20
+
+	firstChunkOffset := int64(0)
+	secondChunkOffset := MaxDataChunkSize
+
+	if idx := int(firstChunkOffset / MaxDataChunkSize); idx != 0 {
+		t.Fatalf("first chunk index = %d, want 0", idx)
+	}
+	if idx := int(secondChunkOffset / MaxDataChunkSize); idx != 1 {
+		t.Fatalf("second chunk index = %d, want 1 (it must not collide with the first chunk's index)", idx)
+	}
+}
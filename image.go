@@ -35,8 +35,11 @@ type ImageMeta struct {
 	ContentType  string             `bson:"contentType" json:"contentType"`               // MIME type
 	Size         int64              `bson:"size" json:"size"`                             // File size in bytes
 	Checksum     string             `bson:"checksum" json:"checksum"`                     // SHA256 checksum
+	Digest       string             `bson:"digest" json:"digest"`                         // Canonical "sha256:<hex>" digest
 	Tags         []string           `bson:"tags,omitempty" json:"tags,omitempty"`         // Tags
 	Metadata     map[string]any     `bson:"metadata,omitempty" json:"metadata,omitempty"` // Custom metadata
+	ManifestID   string             `bson:"manifestID,omitempty" json:"manifestID,omitempty"` // Parent manifest, set for layers imported from a registry
+	SearchText   string             `bson:"searchText" json:"-"`                          // Denormalized originalName + stringified metadata, backs the text index
 	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`                   // Creation time
 	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`                   // Update time
 }
@@ -45,14 +48,33 @@ type ImageMeta struct {
 type ImageStore struct {
 	bucket         *gridfs.Bucket
 	metaCollection *mongo.Collection
+
+	// inflightChunks throttles concurrent PutChunk GridFS writes across all
+	// sessions on this store. Sized from MaxInflightChunks at construction
+	// time, since a channel's capacity can't be changed after make(): later
+	// reassignments of the package var wouldn't reach a channel built at
+	// package init.
+	inflightChunks chan struct{}
 }
 
 // NewImageStore creates a new image store manager
 func NewImageStore() *ImageStore {
-	return &ImageStore{
+	metaCollection, err := GetCollection("imageMetadata")
+	if err != nil {
+		logx.Errorf("Failed to get imageMetadata collection: %v", err)
+	}
+
+	is := &ImageStore{
 		bucket:         GridFSBucket,
-		metaCollection: GetCollection("imageMetadata"),
+		metaCollection: metaCollection,
+		inflightChunks: make(chan struct{}, MaxInflightChunks),
 	}
+
+	if err := is.ensureIndexes(context.Background()); err != nil {
+		logx.Errorf("Failed to ensure image indexes: %v", err)
+	}
+
+	return is
 }
 
 // isValidImageType checks if the content type is a valid image type
@@ -94,8 +116,29 @@ func (is *ImageStore) StoreImageFromReader(ctx context.Context, reader io.Reader
 		return "", ErrInvalidImageType
 	}
 
+	return is.storeBlob(ctx, imageData, filename, contentType, tags, metadata)
+}
+
+// StoreLayerFromReader stores a registry layer blob (tar/gzip), bypassing
+// the image-type allowlist that StoreImageFromReader enforces: real
+// OCI/Docker layers never sniff as one of the supported image MIME types,
+// and are already content-addressed by the caller's registry digest rather
+// than relying on DetectContentType for validation.
+func (is *ImageStore) StoreLayerFromReader(ctx context.Context, reader io.Reader, filename string) (string, error) {
+	layerData, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read layer data: %w", err)
+	}
+
+	return is.storeBlob(ctx, layerData, filename, http.DetectContentType(layerData), nil, nil)
+}
+
+// storeBlob writes data to GridFS under a new ID and records its metadata,
+// de-duplicating on checksum. It backs both StoreImageFromReader and
+// StoreLayerFromReader, which differ only in how contentType is validated.
+func (is *ImageStore) storeBlob(ctx context.Context, data []byte, filename, contentType string, tags []string, metadata map[string]any) (string, error) {
 	// Calculate checksum
-	checksum := fmt.Sprintf("%x", sha256.Sum256(imageData))
+	checksum := fmt.Sprintf("%x", sha256.Sum256(data))
 
 	// Check if image with same checksum already exists
 	existing, err := is.GetImageByChecksum(ctx, checksum)
@@ -114,7 +157,7 @@ func (is *ImageStore) StoreImageFromReader(ctx context.Context, reader io.Reader
 	}
 	defer uploadStream.Close()
 
-	_, err = uploadStream.Write(imageData)
+	_, err = uploadStream.Write(data)
 	if err != nil {
 		return "", fmt.Errorf("failed to write to GridFS: %w", err)
 	}
@@ -126,10 +169,12 @@ func (is *ImageStore) StoreImageFromReader(ctx context.Context, reader io.Reader
 		GridFSID:     gridfsID,
 		OriginalName: filename,
 		ContentType:  contentType,
-		Size:         int64(len(imageData)),
+		Size:         int64(len(data)),
 		Checksum:     checksum,
+		Digest:       canonicalDigest(checksum),
 		Tags:         tags,
 		Metadata:     metadata,
+		SearchText:   buildSearchText(filename, metadata),
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
@@ -145,10 +190,15 @@ func (is *ImageStore) StoreImageFromReader(ctx context.Context, reader io.Reader
 	return imageID, nil
 }
 
-// GetImageMeta retrieves image metadata by ID
-func (is *ImageStore) GetImageMeta(ctx context.Context, imageID string) (*ImageMeta, error) {
+// GetImageMeta retrieves image metadata by xid or by reference (name:tag or
+// name@sha256:<digest>).
+func (is *ImageStore) GetImageMeta(ctx context.Context, idOrRef string) (*ImageMeta, error) {
+	if looksLikeRef(idOrRef) {
+		return is.ResolveRef(ctx, idOrRef)
+	}
+
 	var imageMeta ImageMeta
-	err := is.metaCollection.FindOne(ctx, bson.M{"imageID": imageID}).Decode(&imageMeta)
+	err := is.metaCollection.FindOne(ctx, bson.M{"imageID": idOrRef}).Decode(&imageMeta)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, ErrImageNotFound
@@ -171,6 +221,55 @@ func (is *ImageStore) GetImageByChecksum(ctx context.Context, checksum string) (
 	return &imageMeta, nil
 }
 
+// GetImageByDigest retrieves image metadata by its canonical "sha256:<hex>" digest.
+func (is *ImageStore) GetImageByDigest(ctx context.Context, digest string) (*ImageMeta, error) {
+	var imageMeta ImageMeta
+	err := is.metaCollection.FindOne(ctx, bson.M{"digest": digest}).Decode(&imageMeta)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrImageNotFound
+		}
+		return nil, fmt.Errorf("failed to get image by digest: %w", err)
+	}
+	return &imageMeta, nil
+}
+
+// LinkLayersToManifest stamps manifestID onto every image whose digest is in
+// digests, so registry layer imports can be traced back to their manifest.
+func (is *ImageStore) LinkLayersToManifest(ctx context.Context, digests []string, manifestID string) error {
+	_, err := is.metaCollection.UpdateMany(ctx,
+		bson.M{"digest": bson.M{"$in": digests}},
+		bson.M{"$set": bson.M{"manifestID": manifestID}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to link layers to manifest: %w", err)
+	}
+	return nil
+}
+
+// ImagesByManifest returns every layer image belonging to manifestID, in
+// no particular order.
+func (is *ImageStore) ImagesByManifest(ctx context.Context, manifestID string) ([]*ImageMeta, error) {
+	cursor, err := is.metaCollection.Find(ctx, bson.M{"manifestID": manifestID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find manifest layers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var images []*ImageMeta
+	for cursor.Next(ctx) {
+		var imageMeta ImageMeta
+		if err := cursor.Decode(&imageMeta); err != nil {
+			return nil, fmt.Errorf("failed to decode image metadata: %w", err)
+		}
+		images = append(images, &imageMeta)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+	return images, nil
+}
+
 // GetImageData retrieves image data stream by ID
 func (is *ImageStore) GetImageData(ctx context.Context, imageID string) (io.ReadCloser, *ImageMeta, error) {
 	// First get metadata
@@ -218,27 +317,36 @@ func (is *ImageStore) DownloadImageToFile(ctx context.Context, imageID, outputPa
 	return nil
 }
 
-// DeleteImage deletes image and its metadata
+// DeleteImage is a deprecated alias for ForceDeleteImage, kept for existing
+// callers written before image references existed.
+//
+// Deprecated: use ForceDeleteImage to drop an image regardless of remaining
+// tags, or UntagImage to remove a single reference.
 func (is *ImageStore) DeleteImage(ctx context.Context, imageID string) error {
-	// Get metadata
+	return is.ForceDeleteImage(ctx, imageID)
+}
+
+// ForceDeleteImage drops every reference to imageID along with its blob and
+// metadata, regardless of how many tags still point at it.
+func (is *ImageStore) ForceDeleteImage(ctx context.Context, imageID string) error {
 	imageMeta, err := is.GetImageMeta(ctx, imageID)
 	if err != nil {
 		return err
 	}
 
-	// Delete GridFS file
-	err = is.bucket.Delete(imageMeta.GridFSID)
-	if err != nil {
+	if _, err := is.refCollection().DeleteMany(ctx, bson.M{"imageID": imageMeta.ImageID}); err != nil {
+		return fmt.Errorf("failed to delete refs: %w", err)
+	}
+
+	if err := is.bucket.Delete(imageMeta.GridFSID); err != nil {
 		return fmt.Errorf("failed to delete from GridFS: %w", err)
 	}
 
-	// Delete metadata
-	_, err = is.metaCollection.DeleteOne(ctx, bson.M{"imageID": imageID})
-	if err != nil {
+	if _, err := is.metaCollection.DeleteOne(ctx, bson.M{"imageID": imageMeta.ImageID}); err != nil {
 		return fmt.Errorf("failed to delete metadata: %w", err)
 	}
 
-	logx.Infof("Successfully deleted image: %s", imageID)
+	logx.Infof("Successfully force-deleted image: %s", imageMeta.ImageID)
 	return nil
 }
 
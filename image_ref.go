@@ -0,0 +1,200 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/colin-404/logx"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Error definitions
+var (
+	ErrInvalidImageRef = errors.New("invalid image reference")
+	ErrTagNotFound     = errors.New("tag not found")
+)
+
+// ImageRef is a named reference to an image, either "name:tag" or
+// "name@sha256:<digest>", mirroring the reference/digest split used by
+// Docker/containerd image stores.
+type ImageRef struct {
+	Name   string
+	Tag    string
+	Digest string
+}
+
+// String renders the reference back to its canonical form.
+func (r ImageRef) String() string {
+	if r.Digest != "" {
+		return r.Name + "@" + r.Digest
+	}
+	return r.Name + ":" + r.Tag
+}
+
+// ParseImageRef parses "name:tag" or "name@sha256:<digest>" into an ImageRef.
+func ParseImageRef(ref string) (ImageRef, error) {
+	if name, digest, ok := strings.Cut(ref, "@"); ok {
+		if name == "" || !strings.HasPrefix(digest, "sha256:") {
+			return ImageRef{}, ErrInvalidImageRef
+		}
+		return ImageRef{Name: name, Digest: digest}, nil
+	}
+	if name, tag, ok := strings.Cut(ref, ":"); ok {
+		if name == "" || tag == "" {
+			return ImageRef{}, ErrInvalidImageRef
+		}
+		return ImageRef{Name: name, Tag: tag}, nil
+	}
+	return ImageRef{}, ErrInvalidImageRef
+}
+
+// looksLikeRef reports whether s is shaped like an ImageRef rather than a
+// plain xid (xids never contain ':' or '@').
+func looksLikeRef(s string) bool {
+	return strings.ContainsAny(s, ":@")
+}
+
+// canonicalDigest formats a hex SHA-256 checksum as a "sha256:<hex>" digest.
+func canonicalDigest(checksum string) string {
+	return "sha256:" + checksum
+}
+
+func (is *ImageStore) refCollection() *mongo.Collection {
+	coll, err := GetCollection("imageRefs")
+	if err != nil {
+		logx.Errorf("Failed to get imageRefs collection: %v", err)
+		return nil
+	}
+	return coll
+}
+
+// imageRefDoc is the persisted shape of a (name, tag) -> imageID mapping.
+type imageRefDoc struct {
+	Name      string    `bson:"name"`
+	Tag       string    `bson:"tag"`
+	ImageID   string    `bson:"imageID"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// TagImage points a name:tag reference at imageID, replacing any existing
+// mapping for that name:tag pair.
+func (is *ImageStore) TagImage(ctx context.Context, imageID string, ref ImageRef) error {
+	if ref.Tag == "" {
+		return ErrInvalidImageRef
+	}
+	if _, err := is.GetImageMeta(ctx, imageID); err != nil {
+		return err
+	}
+
+	filter := bson.M{"name": ref.Name, "tag": ref.Tag}
+	update := bson.M{"$set": bson.M{
+		"name":      ref.Name,
+		"tag":       ref.Tag,
+		"imageID":   imageID,
+		"createdAt": time.Now(),
+	}}
+	if _, err := is.refCollection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		return fmt.Errorf("failed to tag image: %w", err)
+	}
+
+	logx.Infof("Tagged image %s as %s", imageID, ref)
+	return nil
+}
+
+// ResolveRef looks up an image by reference, either a name:tag (resolved
+// through the imageRefs collection) or a name@sha256:<digest> (resolved
+// directly against the digest field).
+func (is *ImageStore) ResolveRef(ctx context.Context, ref string) (*ImageMeta, error) {
+	parsed, err := ParseImageRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Digest != "" {
+		var imageMeta ImageMeta
+		err := is.metaCollection.FindOne(ctx, bson.M{"digest": parsed.Digest}).Decode(&imageMeta)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, ErrImageNotFound
+			}
+			return nil, fmt.Errorf("failed to resolve digest ref: %w", err)
+		}
+		return &imageMeta, nil
+	}
+
+	var doc imageRefDoc
+	err = is.refCollection().FindOne(ctx, bson.M{"name": parsed.Name, "tag": parsed.Tag}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrTagNotFound
+		}
+		return nil, fmt.Errorf("failed to resolve tag ref: %w", err)
+	}
+
+	var imageMeta ImageMeta
+	err = is.metaCollection.FindOne(ctx, bson.M{"imageID": doc.ImageID}).Decode(&imageMeta)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrImageNotFound
+		}
+		return nil, fmt.Errorf("failed to get image metadata: %w", err)
+	}
+	return &imageMeta, nil
+}
+
+// UntagImage removes a single name:tag reference. If that was the last
+// reference pointing at the underlying image, the blob and metadata are
+// deleted too; otherwise only the mapping is removed.
+func (is *ImageStore) UntagImage(ctx context.Context, ref string) error {
+	parsed, err := ParseImageRef(ref)
+	if err != nil {
+		return err
+	}
+	if parsed.Tag == "" {
+		return ErrInvalidImageRef
+	}
+
+	var doc imageRefDoc
+	err = is.refCollection().FindOne(ctx, bson.M{"name": parsed.Name, "tag": parsed.Tag}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrTagNotFound
+		}
+		return fmt.Errorf("failed to look up tag: %w", err)
+	}
+
+	if _, err := is.refCollection().DeleteOne(ctx, bson.M{"name": parsed.Name, "tag": parsed.Tag}); err != nil {
+		return fmt.Errorf("failed to untag image: %w", err)
+	}
+
+	remaining, err := is.refCollection().CountDocuments(ctx, bson.M{"imageID": doc.ImageID})
+	if err != nil {
+		return fmt.Errorf("failed to count remaining refs: %w", err)
+	}
+	if remaining > 0 {
+		return nil
+	}
+
+	return is.ForceDeleteImage(ctx, doc.ImageID)
+}
+
+// ListTags returns every tag registered for the given reference name.
+func (is *ImageStore) ListTags(ctx context.Context, name string) ([]string, error) {
+	tags, err := is.refCollection().Distinct(ctx, "tag", bson.M{"name": name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	result := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if s, ok := t.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
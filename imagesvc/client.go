@@ -0,0 +1,158 @@
+package imagesvc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ImagesClient is a thin wrapper around a gRPC connection to the Images service.
+type ImagesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewImagesClient wraps cc as an ImagesClient.
+func NewImagesClient(cc grpc.ClientConnInterface) *ImagesClient {
+	return &ImagesClient{cc: cc}
+}
+
+func (c *ImagesClient) Get(ctx context.Context, req *GetRequest, opts ...grpc.CallOption) (*ImageMeta, error) {
+	out := new(ImageMeta)
+	if err := c.cc.Invoke(ctx, "/xid.common.imagesvc.Images/Get", req, out, withJSONCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ImagesClient) Delete(ctx context.Context, req *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	if err := c.cc.Invoke(ctx, "/xid.common.imagesvc.Images/Delete", req, out, withJSONCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ImagesClient) UpdateTags(ctx context.Context, req *UpdateTagsRequest, opts ...grpc.CallOption) (*ImageMeta, error) {
+	out := new(ImageMeta)
+	if err := c.cc.Invoke(ctx, "/xid.common.imagesvc.Images/UpdateTags", req, out, withJSONCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ImagesClient) UpdateMetadata(ctx context.Context, req *UpdateMetadataRequest, opts ...grpc.CallOption) (*ImageMeta, error) {
+	out := new(ImageMeta)
+	if err := c.cc.Invoke(ctx, "/xid.common.imagesvc.Images/UpdateMetadata", req, out, withJSONCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ImagesClient) Stats(ctx context.Context, req *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	if err := c.cc.Invoke(ctx, "/xid.common.imagesvc.Images/Stats", req, out, withJSONCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Images_ListClient is the client-side stream for List. Once Recv returns
+// io.EOF, NextCursor reports the cursor for the following page, or "" if the
+// server returned a short (final) page.
+type Images_ListClient interface {
+	Recv() (*ImageMeta, error)
+	NextCursor() string
+	grpc.ClientStream
+}
+
+func (c *ImagesClient) List(ctx context.Context, req *ListRequest, opts ...grpc.CallOption) (Images_ListClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Images_serviceDesc.Streams[0], "/xid.common.imagesvc.Images/List", withJSONCodec(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &imagesListClient{stream}, nil
+}
+
+type imagesListClient struct{ grpc.ClientStream }
+
+func (c *imagesListClient) Recv() (*ImageMeta, error) {
+	m := new(ImageMeta)
+	if err := c.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *imagesListClient) NextCursor() string {
+	values := c.ClientStream.Trailer().Get(nextCursorMetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Images_PutClient is the client-side stream for Put.
+type Images_PutClient interface {
+	Send(*PutChunk) error
+	CloseAndRecv() (*ImageMeta, error)
+	grpc.ClientStream
+}
+
+func (c *ImagesClient) Put(ctx context.Context, opts ...grpc.CallOption) (Images_PutClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Images_serviceDesc.Streams[1], "/xid.common.imagesvc.Images/Put", withJSONCodec(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return &imagesPutClient{stream}, nil
+}
+
+type imagesPutClient struct{ grpc.ClientStream }
+
+func (c *imagesPutClient) Send(m *PutChunk) error { return c.ClientStream.SendMsg(m) }
+
+func (c *imagesPutClient) CloseAndRecv() (*ImageMeta, error) {
+	if err := c.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImageMeta)
+	if err := c.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Images_DownloadClient is the client-side stream for Download.
+type Images_DownloadClient interface {
+	Recv() (*DownloadChunk, error)
+	grpc.ClientStream
+}
+
+func (c *ImagesClient) Download(ctx context.Context, req *DownloadRequest, opts ...grpc.CallOption) (Images_DownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Images_serviceDesc.Streams[2], "/xid.common.imagesvc.Images/Download", withJSONCodec(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &imagesDownloadClient{stream}, nil
+}
+
+type imagesDownloadClient struct{ grpc.ClientStream }
+
+func (c *imagesDownloadClient) Recv() (*DownloadChunk, error) {
+	m := new(DownloadChunk)
+	if err := c.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,42 @@
+package imagesvc
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// jsonCodecName is the gRPC content-subtype jsonCodec is registered under.
+// grpc-go picks a call's codec from the content-subtype negotiated via
+// grpc.CallContentSubtype on the client and the incoming
+// "application/grpc+<subtype>" content-type header on the server, so
+// registering this codec and always dialing with it wires up both sides
+// without protoc-generated types.
+const jsonCodecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec lets the hand-written message types in this package, which don't
+// implement proto.Message, travel over gRPC. The default codec type-asserts
+// every message to proto.Message and fails, so every RPC would error without
+// a codec like this registered.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return jsonCodecName }
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// withJSONCodec prepends grpc.CallContentSubtype so every client call is
+// negotiated over jsonCodec regardless of what the caller passes in opts.
+func withJSONCodec(opts []grpc.CallOption) []grpc.CallOption {
+	return append([]grpc.CallOption{grpc.CallContentSubtype(jsonCodecName)}, opts...)
+}
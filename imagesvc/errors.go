@@ -0,0 +1,32 @@
+package imagesvc
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	common "xid-protocol/common"
+)
+
+// MapError translates ImageStore domain errors to gRPC status errors, so the
+// same mapping can be reused by the HTTP layer when it needs an equivalent
+// status code.
+func MapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, common.ErrImageNotFound), errors.Is(err, common.ErrTagNotFound), errors.Is(err, common.ErrUploadSessionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, common.ErrInvalidImageType), errors.Is(err, common.ErrInvalidImageRef), errors.Is(err, common.ErrChunkOutOfOrder), errors.Is(err, common.ErrUploadIncomplete):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, common.ErrImageAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, common.ErrUploadSessionExpired):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
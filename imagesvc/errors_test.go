@@ -0,0 +1,77 @@
+package imagesvc
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	common "xid-protocol/common"
+)
+
+func TestMapError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"image not found", common.ErrImageNotFound, codes.NotFound},
+		{"tag not found", common.ErrTagNotFound, codes.NotFound},
+		{"upload session not found", common.ErrUploadSessionNotFound, codes.NotFound},
+		{"invalid image type", common.ErrInvalidImageType, codes.InvalidArgument},
+		{"invalid ref", common.ErrInvalidImageRef, codes.InvalidArgument},
+		{"chunk out of order", common.ErrChunkOutOfOrder, codes.InvalidArgument},
+		{"upload incomplete", common.ErrUploadIncomplete, codes.InvalidArgument},
+		{"already exists", common.ErrImageAlreadyExists, codes.AlreadyExists},
+		{"session expired", common.ErrUploadSessionExpired, codes.DeadlineExceeded},
+		{"unmapped error", errors.New("boom"), codes.Internal},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			st, ok := status.FromError(MapError(tc.err))
+			if !ok {
+				t.Fatalf("MapError(%v) did not return a status error", tc.err)
+			}
+			if st.Code() != tc.code {
+				t.Fatalf("MapError(%v) code = %v, want %v", tc.err, st.Code(), tc.code)
+			}
+		})
+	}
+}
+
+func TestMapErrorNil(t *testing.T) {
+	if err := MapError(nil); err != nil {
+		t.Fatalf("MapError(nil) = %v, want nil", err)
+	}
+}
+
+// TestJSONCodecRoundTrip guards against the messages in this package
+// silently losing the ability to travel over gRPC: the default codec
+// requires proto.Message, which these hand-written structs don't implement,
+// so jsonCodec (registered in codec.go) has to round-trip them instead.
+func TestJSONCodecRoundTrip(t *testing.T) {
+	var codec jsonCodec
+
+	in := &ImageMeta{
+		ImageID:      "img_1",
+		OriginalName: "layer.tar",
+		Tags:         []string{"a", "b"},
+		Metadata:     map[string]string{"k": "v"},
+	}
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	out := new(ImageMeta)
+	if err := codec.Unmarshal(data, out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if out.ImageID != in.ImageID || out.OriginalName != in.OriginalName {
+		t.Fatalf("round-tripped message = %+v, want %+v", out, in)
+	}
+}
@@ -0,0 +1,68 @@
+package imagesvc
+
+// The message types below mirror images.proto. They are plain structs
+// rather than protoc-gen-go output, so they travel over gRPC via jsonCodec
+// (see codec.go) instead of the default proto.Message-based codec.
+
+type ImageMeta struct {
+	ImageID         string
+	OriginalName    string
+	ContentType     string
+	Size            int64
+	Checksum        string
+	Digest          string
+	Tags            []string
+	Metadata        map[string]string
+	CreatedAtUnixMs int64
+	UpdatedAtUnixMs int64
+}
+
+type GetRequest struct {
+	IDOrRef string
+}
+
+type ListRequest struct {
+	Tags   []string
+	Limit  int64
+	Cursor string
+}
+
+type PutChunk struct {
+	Filename     string
+	ExpectedSize int64
+	Data         []byte
+}
+
+type DeleteRequest struct {
+	ImageID string // used when Force is true: deletes regardless of remaining tags
+	Ref     string // used when Force is false: the "name:tag" reference to untag (digest refs are rejected)
+	Force   bool
+}
+
+type DeleteResponse struct{}
+
+type UpdateTagsRequest struct {
+	ImageID string
+	Tags    []string
+}
+
+type UpdateMetadataRequest struct {
+	ImageID  string
+	Metadata map[string]string
+}
+
+type StatsRequest struct{}
+
+type StatsResponse struct {
+	TotalCount int64
+	TotalSize  int64
+	AvgSize    float64
+}
+
+type DownloadRequest struct {
+	ImageID string
+}
+
+type DownloadChunk struct {
+	Data []byte
+}
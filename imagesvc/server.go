@@ -0,0 +1,410 @@
+package imagesvc
+
+import (
+	"context"
+	"io"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	common "xid-protocol/common"
+)
+
+// nextCursorMetadataKey is the trailer key List uses to carry the cursor for
+// the following page, since the response stream only carries ImageMeta
+// values and has nowhere else to put it.
+const nextCursorMetadataKey = "x-next-cursor"
+
+// ImagesServer is the server API for the Images service.
+type ImagesServer interface {
+	Get(context.Context, *GetRequest) (*ImageMeta, error)
+	List(*ListRequest, Images_ListServer) error
+	Put(Images_PutServer) error
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	UpdateTags(context.Context, *UpdateTagsRequest) (*ImageMeta, error)
+	UpdateMetadata(context.Context, *UpdateMetadataRequest) (*ImageMeta, error)
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	Download(*DownloadRequest, Images_DownloadServer) error
+}
+
+// Images_ListServer is the server-side stream for List.
+type Images_ListServer interface {
+	Send(*ImageMeta) error
+	grpc.ServerStream
+}
+
+// Images_PutServer is the client-streaming server side for Put.
+type Images_PutServer interface {
+	Recv() (*PutChunk, error)
+	SendAndClose(*ImageMeta) error
+	grpc.ServerStream
+}
+
+// Images_DownloadServer is the server-side stream for Download.
+type Images_DownloadServer interface {
+	Send(*DownloadChunk) error
+	grpc.ServerStream
+}
+
+// RegisterImagesServer registers an ImagesServer backed by store on registrar.
+func RegisterImagesServer(registrar grpc.ServiceRegistrar, store *common.ImageStore) {
+	registrar.RegisterService(&_Images_serviceDesc, &imagesServer{store: store})
+}
+
+// imagesServer adapts *common.ImageStore to the Images gRPC service.
+type imagesServer struct {
+	store *common.ImageStore
+}
+
+func (s *imagesServer) Get(ctx context.Context, req *GetRequest) (*ImageMeta, error) {
+	meta, err := s.store.GetImageMeta(ctx, req.IDOrRef)
+	if err != nil {
+		return nil, MapError(err)
+	}
+	return toProtoMeta(meta), nil
+}
+
+func (s *imagesServer) List(req *ListRequest, stream Images_ListServer) error {
+	offset := decodeCursor(req.Cursor)
+	images, err := s.store.ListImages(stream.Context(), req.Tags, req.Limit, offset)
+	if err != nil {
+		return MapError(err)
+	}
+	for _, img := range images {
+		if err := stream.Send(toProtoMeta(img)); err != nil {
+			return err
+		}
+	}
+
+	// A full page means there may be more to fetch; a short page means this
+	// was the last one, so there's no cursor to hand back.
+	if req.Limit > 0 && int64(len(images)) == req.Limit {
+		nextCursor := encodeCursor(offset + int64(len(images)))
+		stream.SetTrailer(metadata.Pairs(nextCursorMetadataKey, nextCursor))
+	}
+	return nil
+}
+
+func (s *imagesServer) Put(stream Images_PutServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	session, err := s.store.StoreImageChunked(stream.Context(), first.Filename, common.ChunkedUploadOptions{
+		ExpectedSize: first.ExpectedSize,
+	})
+	if err != nil {
+		return MapError(err)
+	}
+
+	var offset int64
+	var buf []byte
+	// putFull writes every MaxDataChunkSize-aligned chunk currently
+	// buffered; final also flushes the shorter trailing chunk. Client
+	// stream messages rarely land on MaxDataChunkSize boundaries (gRPC's
+	// default max-message size equals MaxDataChunkSize, so a client can't
+	// even fit one aligned chunk in a single message), but PutChunk
+	// requires aligned chunks, so re-chunk here before calling it.
+	putFull := func(final bool) error {
+		for int64(len(buf)) >= common.MaxDataChunkSize || (final && len(buf) > 0) {
+			n := common.MaxDataChunkSize
+			if int64(len(buf)) < n {
+				n = int64(len(buf))
+			}
+			if err := s.store.PutChunk(stream.Context(), session.SessionID, offset, buf[:n]); err != nil {
+				return MapError(err)
+			}
+			offset += n
+			buf = buf[n:]
+		}
+		return nil
+	}
+
+	buf = append(buf, first.Data...)
+	if err := putFull(false); err != nil {
+		return err
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		buf = append(buf, chunk.Data...)
+		if err := putFull(false); err != nil {
+			return err
+		}
+	}
+
+	if err := putFull(true); err != nil {
+		return err
+	}
+
+	meta, err := s.store.CompleteUpload(stream.Context(), session.SessionID)
+	if err != nil {
+		return MapError(err)
+	}
+	return stream.SendAndClose(toProtoMeta(meta))
+}
+
+func (s *imagesServer) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	var err error
+	if req.Force {
+		err = s.store.ForceDeleteImage(ctx, req.ImageID)
+	} else {
+		err = s.store.UntagImage(ctx, req.Ref)
+	}
+	if err != nil {
+		return nil, MapError(err)
+	}
+	return &DeleteResponse{}, nil
+}
+
+func (s *imagesServer) UpdateTags(ctx context.Context, req *UpdateTagsRequest) (*ImageMeta, error) {
+	if err := s.store.UpdateImageTags(ctx, req.ImageID, req.Tags); err != nil {
+		return nil, MapError(err)
+	}
+	meta, err := s.store.GetImageMeta(ctx, req.ImageID)
+	if err != nil {
+		return nil, MapError(err)
+	}
+	return toProtoMeta(meta), nil
+}
+
+func (s *imagesServer) UpdateMetadata(ctx context.Context, req *UpdateMetadataRequest) (*ImageMeta, error) {
+	metadata := make(map[string]any, len(req.Metadata))
+	for k, v := range req.Metadata {
+		metadata[k] = v
+	}
+	if err := s.store.UpdateImageMetadata(ctx, req.ImageID, metadata); err != nil {
+		return nil, MapError(err)
+	}
+	meta, err := s.store.GetImageMeta(ctx, req.ImageID)
+	if err != nil {
+		return nil, MapError(err)
+	}
+	return toProtoMeta(meta), nil
+}
+
+func (s *imagesServer) Stats(ctx context.Context, _ *StatsRequest) (*StatsResponse, error) {
+	stats, err := s.store.GetImageStats(ctx)
+	if err != nil {
+		return nil, MapError(err)
+	}
+	resp := &StatsResponse{}
+	if v, ok := stats["totalCount"].(int64); ok {
+		resp.TotalCount = v
+	}
+	if v, ok := stats["totalSize"].(int64); ok {
+		resp.TotalSize = v
+	}
+	if v, ok := stats["avgSize"].(float64); ok {
+		resp.AvgSize = v
+	}
+	return resp, nil
+}
+
+func (s *imagesServer) Download(req *DownloadRequest, stream Images_DownloadServer) error {
+	data, _, err := s.store.GetImageData(stream.Context(), req.ImageID)
+	if err != nil {
+		return MapError(err)
+	}
+	defer data.Close()
+
+	buf := make([]byte, common.MaxDataChunkSize)
+	for {
+		n, readErr := data.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&DownloadChunk{Data: append([]byte(nil), buf[:n]...)}); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func toProtoMeta(m *common.ImageMeta) *ImageMeta {
+	metadata := make(map[string]string, len(m.Metadata))
+	for k, v := range m.Metadata {
+		metadata[k] = toString(v)
+	}
+	return &ImageMeta{
+		ImageID:         m.ImageID,
+		OriginalName:    m.OriginalName,
+		ContentType:     m.ContentType,
+		Size:            m.Size,
+		Checksum:        m.Checksum,
+		Digest:          m.Digest,
+		Tags:            m.Tags,
+		Metadata:        metadata,
+		CreatedAtUnixMs: m.CreatedAt.UnixMilli(),
+		UpdatedAtUnixMs: m.UpdatedAt.UnixMilli(),
+	}
+}
+
+func toString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func decodeCursor(cursor string) int64 {
+	if cursor == "" {
+		return 0
+	}
+	var offset int64
+	for _, c := range cursor {
+		if c < '0' || c > '9' {
+			return 0
+		}
+		offset = offset*10 + int64(c-'0')
+	}
+	return offset
+}
+
+func encodeCursor(offset int64) string {
+	return strconv.FormatInt(offset, 10)
+}
+
+var _Images_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "xid.common.imagesvc.Images",
+	HandlerType: (*ImagesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Get", Handler: _Images_Get_Handler},
+		{MethodName: "Delete", Handler: _Images_Delete_Handler},
+		{MethodName: "UpdateTags", Handler: _Images_UpdateTags_Handler},
+		{MethodName: "UpdateMetadata", Handler: _Images_UpdateMetadata_Handler},
+		{MethodName: "Stats", Handler: _Images_Stats_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "List", Handler: _Images_List_Handler, ServerStreams: true},
+		{StreamName: "Put", Handler: _Images_Put_Handler, ClientStreams: true},
+		{StreamName: "Download", Handler: _Images_Download_Handler, ServerStreams: true},
+	},
+}
+
+func _Images_Get_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(GetRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImagesServer).Get(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/xid.common.imagesvc.Images/Get"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(ImagesServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+func _Images_Delete_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(DeleteRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImagesServer).Delete(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/xid.common.imagesvc.Images/Delete"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(ImagesServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+func _Images_UpdateTags_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(UpdateTagsRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImagesServer).UpdateTags(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/xid.common.imagesvc.Images/UpdateTags"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(ImagesServer).UpdateTags(ctx, req.(*UpdateTagsRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+func _Images_UpdateMetadata_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(UpdateMetadataRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImagesServer).UpdateMetadata(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/xid.common.imagesvc.Images/UpdateMetadata"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(ImagesServer).UpdateMetadata(ctx, req.(*UpdateMetadataRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+func _Images_Stats_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(StatsRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImagesServer).Stats(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/xid.common.imagesvc.Images/Stats"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(ImagesServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+func _Images_List_Handler(srv any, stream grpc.ServerStream) error {
+	req := new(ListRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(ImagesServer).List(req, &imagesListServer{stream})
+}
+
+func _Images_Put_Handler(srv any, stream grpc.ServerStream) error {
+	return srv.(ImagesServer).Put(&imagesPutServer{stream})
+}
+
+func _Images_Download_Handler(srv any, stream grpc.ServerStream) error {
+	req := new(DownloadRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(ImagesServer).Download(req, &imagesDownloadServer{stream})
+}
+
+type imagesListServer struct{ grpc.ServerStream }
+
+func (s *imagesListServer) Send(m *ImageMeta) error { return s.ServerStream.SendMsg(m) }
+
+type imagesPutServer struct{ grpc.ServerStream }
+
+func (s *imagesPutServer) Recv() (*PutChunk, error) {
+	m := new(PutChunk)
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *imagesPutServer) SendAndClose(m *ImageMeta) error { return s.ServerStream.SendMsg(m) }
+
+type imagesDownloadServer struct{ grpc.ServerStream }
+
+func (s *imagesDownloadServer) Send(m *DownloadChunk) error { return s.ServerStream.SendMsg(m) }
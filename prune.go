@@ -0,0 +1,160 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/colin-404/logx"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MaxPruneWorkers bounds how many images are deleted concurrently by PruneImages.
+var MaxPruneWorkers = 8
+
+// PruneFilter selects which images PruneImages should consider for removal.
+type PruneFilter struct {
+	Until         time.Time         // only consider images created before this time
+	MinSize       int64             // only consider images at least this large, in bytes
+	MaxSize       int64             // only consider images at most this large, 0 means unbounded
+	Dangling      bool              // only consider images with no tags and no external references
+	LabelSelector map[string]string // Metadata key/value pairs that must all match
+	TagGlob       string            // only consider images with at least one tag matching this glob
+	TagNotGlob    string            // exclude images with any tag matching this glob
+	DryRun        bool              // compute the report without deleting anything
+}
+
+// PruneReport summarizes the result of a PruneImages call.
+type PruneReport struct {
+	ReclaimedBytes  int64
+	DeletedImageIDs []string
+	Errors          map[string]string // imageID -> error message, for images that failed to delete
+}
+
+// PruneImages deletes images matching filter, analogous to `docker image prune`.
+// Deletion runs in bounded-concurrency workers and a single image's failure
+// does not abort the rest of the batch. With filter.DryRun set, the same
+// report is returned without mutating any state.
+func (is *ImageStore) PruneImages(ctx context.Context, filter PruneFilter) (*PruneReport, error) {
+	candidates, err := is.pruneCandidates(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneReport{Errors: make(map[string]string)}
+	if len(candidates) == 0 {
+		return report, nil
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, MaxPruneWorkers)
+	)
+
+	for _, candidate := range candidates {
+		candidate := candidate
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if !filter.DryRun {
+				if err := is.ForceDeleteImage(ctx, candidate.ImageID); err != nil {
+					mu.Lock()
+					report.Errors[candidate.ImageID] = err.Error()
+					mu.Unlock()
+					return
+				}
+			}
+
+			mu.Lock()
+			report.ReclaimedBytes += candidate.Size
+			report.DeletedImageIDs = append(report.DeletedImageIDs, candidate.ImageID)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	logx.Infof("Pruned %d image(s), reclaimed %d bytes (dryRun=%v)", len(report.DeletedImageIDs), report.ReclaimedBytes, filter.DryRun)
+	return report, nil
+}
+
+// pruneCandidates runs a single aggregation that projects every image
+// matching filter, along with whether it still has any references.
+func (is *ImageStore) pruneCandidates(ctx context.Context, filter PruneFilter) ([]*ImageMeta, error) {
+	match := bson.M{}
+	if !filter.Until.IsZero() {
+		match["createdAt"] = bson.M{"$lt": filter.Until}
+	}
+	if filter.MinSize > 0 || filter.MaxSize > 0 {
+		sizeFilter := bson.M{}
+		if filter.MinSize > 0 {
+			sizeFilter["$gte"] = filter.MinSize
+		}
+		if filter.MaxSize > 0 {
+			sizeFilter["$lte"] = filter.MaxSize
+		}
+		match["size"] = sizeFilter
+	}
+	for key, value := range filter.LabelSelector {
+		match["metadata."+key] = value
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$lookup": bson.M{
+			"from":         "imageRefs",
+			"localField":   "imageID",
+			"foreignField": "imageID",
+			"as":           "refs",
+		}},
+	}
+
+	cursor, err := is.metaCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate prune candidates: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var candidates []*ImageMeta
+	for cursor.Next(ctx) {
+		var doc struct {
+			ImageMeta `bson:",inline"`
+			Refs      []bson.M `bson:"refs"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode prune candidate: %w", err)
+		}
+
+		if filter.Dangling && (len(doc.Tags) > 0 || len(doc.Refs) > 0) {
+			continue
+		}
+		if filter.TagGlob != "" && !anyTagMatches(doc.Tags, filter.TagGlob) {
+			continue
+		}
+		if filter.TagNotGlob != "" && anyTagMatches(doc.Tags, filter.TagNotGlob) {
+			continue
+		}
+
+		meta := doc.ImageMeta
+		candidates = append(candidates, &meta)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+
+	return candidates, nil
+}
+
+func anyTagMatches(tags []string, glob string) bool {
+	for _, tag := range tags {
+		if ok, err := path.Match(glob, tag); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package registry
+
+import (
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// remoteOption builds the remote.Option carrying credentials for auth. When
+// auth is the zero value, credentials are resolved from DOCKER_CONFIG via the
+// default keychain instead.
+func remoteOption(auth AuthConfig) remote.Option {
+	switch {
+	case auth.BearerToken != "":
+		return remote.WithAuth(&authn.Bearer{Token: auth.BearerToken})
+	case auth.Username != "" || auth.Password != "":
+		return remote.WithAuth(&authn.Basic{Username: auth.Username, Password: auth.Password})
+	default:
+		return remote.WithAuthFromKeychain(authn.DefaultKeychain)
+	}
+}
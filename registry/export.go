@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/colin-404/logx"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	common "xid-protocol/common"
+)
+
+// ExportToRegistry reconstructs the manifest that produced imageID's layers
+// and pushes it back out to ref.
+func ExportToRegistry(ctx context.Context, store *common.ImageStore, imageID, ref string, auth AuthConfig) error {
+	meta, err := store.GetImageMeta(ctx, imageID)
+	if err != nil {
+		return err
+	}
+	if meta.ManifestID == "" {
+		return fmt.Errorf("image %s was not imported from a registry, no manifest to export", imageID)
+	}
+
+	manifestColl, err := common.GetCollection("imageManifests")
+	if err != nil {
+		return fmt.Errorf("failed to get imageManifests collection: %w", err)
+	}
+
+	var manifestDoc ImageManifest
+	err = manifestColl.FindOne(ctx, manifestFilter(meta.ManifestID)).Decode(&manifestDoc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return fmt.Errorf("manifest %s not found", meta.ManifestID)
+		}
+		return fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	layers, err := store.ImagesByManifest(ctx, meta.ManifestID)
+	if err != nil {
+		return err
+	}
+	if len(layers) != len(manifestDoc.LayerDigests) {
+		return fmt.Errorf("manifest %s expects %d layers, found %d stored", meta.ManifestID, len(manifestDoc.LayerDigests), len(layers))
+	}
+
+	byDigest := make(map[string]*common.ImageMeta, len(layers))
+	for _, layer := range layers {
+		byDigest[layer.Digest] = layer
+	}
+
+	img, err := buildImage(ctx, store, manifestDoc, byDigest)
+	if err != nil {
+		return err
+	}
+
+	parsedRef, err := name.ParseReference(ref)
+	if err != nil {
+		return fmt.Errorf("failed to parse registry reference: %w", err)
+	}
+
+	if err := remote.Write(parsedRef, img, remoteOption(auth), remote.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to push manifest: %w", err)
+	}
+
+	logx.Infof("Exported manifest %s to %s (%d layers)", meta.ManifestID, ref, len(layers))
+	return nil
+}
+
+// buildImage reassembles a v1.Image from the layers originally stored by
+// ImportFromRegistry, in manifest order.
+func buildImage(ctx context.Context, store *common.ImageStore, manifestDoc ImageManifest, byDigest map[string]*common.ImageMeta) (v1.Image, error) {
+	img := mutate.MediaType(empty.Image, types.MediaType(manifestDoc.MediaType))
+
+	for _, digest := range manifestDoc.LayerDigests {
+		layerMeta, ok := byDigest[digest]
+		if !ok {
+			return nil, fmt.Errorf("missing stored layer for digest %s", digest)
+		}
+
+		data, _, err := store.GetImageData(ctx, layerMeta.ImageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer %s: %w", layerMeta.ImageID, err)
+		}
+		buf, err := io.ReadAll(data)
+		data.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to buffer layer %s: %w", layerMeta.ImageID, err)
+		}
+
+		layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(buf)), nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build layer %s: %w", layerMeta.ImageID, err)
+		}
+
+		img, err = mutate.AppendLayers(img, layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to append layer %s: %w", layerMeta.ImageID, err)
+		}
+	}
+
+	return img, nil
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colin-404/logx"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rs/xid"
+
+	common "xid-protocol/common"
+)
+
+// ImportFromRegistry pulls ref from an OCI/Docker registry and stores each
+// layer as a separate ImageMeta entry linked by a parent manifestID. Layers
+// that already exist (by checksum) are reused rather than stored twice. It
+// returns the imageID of the top-most layer.
+func ImportFromRegistry(ctx context.Context, store *common.ImageStore, ref string, auth AuthConfig) (string, error) {
+	parsedRef, err := name.ParseReference(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse registry reference: %w", err)
+	}
+
+	img, err := remote.Image(parsedRef, remoteOption(auth), remote.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve manifest: %w", err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return "", fmt.Errorf("failed to list layers: %w", err)
+	}
+
+	layerDigests := make([]string, 0, len(layers))
+	var lastImageID string
+	for _, layer := range layers {
+		imageID, digest, err := importLayer(ctx, store, layer)
+		if err != nil {
+			return "", err
+		}
+		layerDigests = append(layerDigests, digest)
+		lastImageID = imageID
+	}
+
+	manifestJSON, err := img.RawManifest()
+	if err != nil {
+		return "", fmt.Errorf("failed to read raw manifest: %w", err)
+	}
+
+	manifestID := xid.New().String()
+	doc := ImageManifest{
+		ManifestID:   manifestID,
+		Ref:          ref,
+		MediaType:    string(manifest.MediaType),
+		ConfigDigest: manifest.Config.Digest.String(),
+		LayerDigests: layerDigests,
+		ManifestJSON: manifestJSON,
+		CreatedAt:    time.Now(),
+	}
+	manifestColl, err := common.GetCollection("imageManifests")
+	if err != nil {
+		return "", fmt.Errorf("failed to get imageManifests collection: %w", err)
+	}
+	if _, err := manifestColl.InsertOne(ctx, doc); err != nil {
+		return "", fmt.Errorf("failed to save manifest: %w", err)
+	}
+
+	if err := store.LinkLayersToManifest(ctx, layerDigests, manifestID); err != nil {
+		logx.Errorf("Failed to link layers to manifest %s: %v", manifestID, err)
+	}
+
+	logx.Infof("Imported %s as manifest %s (%d layers)", ref, manifestID, len(layers))
+	return lastImageID, nil
+}
+
+func importLayer(ctx context.Context, store *common.ImageStore, layer v1.Layer) (imageID, digest string, err error) {
+	d, err := layer.Digest()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read layer digest: %w", err)
+	}
+
+	if existing, err := store.GetImageByDigest(ctx, d.String()); err == nil && existing != nil {
+		return existing.ImageID, d.String(), nil
+	} else if err != nil && !errors.Is(err, common.ErrImageNotFound) {
+		return "", "", err
+	}
+
+	// Use the compressed (registry) bytes so the stored checksum equals d,
+	// the digest dedup and manifest linking key off.
+	reader, err := layer.Compressed()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open layer: %w", err)
+	}
+	defer reader.Close()
+
+	id, err := store.StoreLayerFromReader(ctx, reader, d.Hex+".layer")
+	if err != nil && !errors.Is(err, common.ErrImageAlreadyExists) {
+		return "", "", fmt.Errorf("failed to store layer %s: %w", d, err)
+	}
+	return id, d.String(), nil
+}
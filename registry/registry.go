@@ -0,0 +1,36 @@
+// Package registry imports and exports ImageStore blobs to/from OCI/Docker
+// registries, letting operators seed the GridFS-backed store from
+// public/private registries and push stored layers back out.
+package registry
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AuthConfig holds credentials for a registry. Either Username/Password
+// (basic auth) or BearerToken may be set; if neither is set, DOCKER_CONFIG is
+// consulted for credentials instead.
+type AuthConfig struct {
+	Username    string
+	Password    string
+	BearerToken string
+}
+
+// ImageManifest records the manifest for a set of layers imported from a
+// registry, so ExportToRegistry can reconstruct it without re-resolving the
+// original reference.
+type ImageManifest struct {
+	ManifestID   string    `bson:"manifestID" json:"manifestID"`
+	Ref          string    `bson:"ref" json:"ref"`
+	MediaType    string    `bson:"mediaType" json:"mediaType"`
+	ConfigDigest string    `bson:"configDigest" json:"configDigest"`
+	LayerDigests []string  `bson:"layerDigests" json:"layerDigests"` // ordered, outermost layer last
+	ManifestJSON []byte    `bson:"manifestJSON" json:"manifestJSON"`
+	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+func manifestFilter(manifestID string) bson.M {
+	return bson.M{"manifestID": manifestID}
+}
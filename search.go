@@ -0,0 +1,222 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Query builds the bson.M filter consumed by SearchImages.
+type Query struct {
+	and []bson.M
+}
+
+// NewQuery starts an empty Query.
+func NewQuery() *Query {
+	return &Query{}
+}
+
+func (q *Query) add(cond bson.M) *Query {
+	q.and = append(q.and, cond)
+	return q
+}
+
+// OriginalNameContains matches OriginalName by case-insensitive substring.
+func (q *Query) OriginalNameContains(substr string) *Query {
+	return q.add(bson.M{"originalName": bson.M{"$regex": regexQuoteMeta(substr), "$options": "i"}})
+}
+
+// OriginalNameExact matches OriginalName exactly.
+func (q *Query) OriginalNameExact(name string) *Query {
+	return q.add(bson.M{"originalName": name})
+}
+
+// ChecksumPrefix matches images whose checksum starts with prefix.
+func (q *Query) ChecksumPrefix(prefix string) *Query {
+	return q.add(bson.M{"checksum": bson.M{"$regex": "^" + regexQuoteMeta(prefix)}})
+}
+
+// ChecksumExact matches a single checksum.
+func (q *Query) ChecksumExact(checksum string) *Query {
+	return q.add(bson.M{"checksum": checksum})
+}
+
+// MetadataEquals matches Metadata[key] == value.
+func (q *Query) MetadataEquals(key string, value any) *Query {
+	return q.add(bson.M{"metadata." + key: value})
+}
+
+// MetadataRange matches Metadata[key] within [min, max].
+func (q *Query) MetadataRange(key string, min, max any) *Query {
+	cond := bson.M{}
+	if min != nil {
+		cond["$gte"] = min
+	}
+	if max != nil {
+		cond["$lte"] = max
+	}
+	if len(cond) == 0 {
+		return q
+	}
+	return q.add(bson.M{"metadata." + key: cond})
+}
+
+// ContentTypeIn matches images whose ContentType is one of types.
+func (q *Query) ContentTypeIn(types []string) *Query {
+	return q.add(bson.M{"contentType": bson.M{"$in": types}})
+}
+
+// CreatedAtRange matches images created within [from, to]. A zero time.Time
+// on either side leaves that bound unset.
+func (q *Query) CreatedAtRange(from, to time.Time) *Query {
+	cond := bson.M{}
+	if !from.IsZero() {
+		cond["$gte"] = from
+	}
+	if !to.IsZero() {
+		cond["$lte"] = to
+	}
+	if len(cond) == 0 {
+		return q
+	}
+	return q.add(bson.M{"createdAt": cond})
+}
+
+// SizeRange matches images whose Size is within [min, max]. A zero value on
+// either side leaves that bound unset.
+func (q *Query) SizeRange(min, max int64) *Query {
+	cond := bson.M{}
+	if min > 0 {
+		cond["$gte"] = min
+	}
+	if max > 0 {
+		cond["$lte"] = max
+	}
+	if len(cond) == 0 {
+		return q
+	}
+	return q.add(bson.M{"size": cond})
+}
+
+// TagsAll requires every tag in tags to be present.
+func (q *Query) TagsAll(tags []string) *Query {
+	return q.add(bson.M{"tags": bson.M{"$all": tags}})
+}
+
+// TagsAny requires at least one tag in tags to be present.
+func (q *Query) TagsAny(tags []string) *Query {
+	return q.add(bson.M{"tags": bson.M{"$in": tags}})
+}
+
+// TagsNone excludes images that have any tag in tags.
+func (q *Query) TagsNone(tags []string) *Query {
+	return q.add(bson.M{"tags": bson.M{"$nin": tags}})
+}
+
+// Text runs a free-text query against the text index over OriginalName and
+// stringified Metadata values.
+func (q *Query) Text(text string) *Query {
+	return q.add(bson.M{"$text": bson.M{"$search": text}})
+}
+
+// Build translates the Query into a single bson.M filter.
+func (q *Query) Build() bson.M {
+	if len(q.and) == 0 {
+		return bson.M{}
+	}
+	if len(q.and) == 1 {
+		return q.and[0]
+	}
+	return bson.M{"$and": q.and}
+}
+
+func regexQuoteMeta(s string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`, `.`, `\.`, `+`, `\+`, `*`, `\*`, `?`, `\?`, `(`, `\(`, `)`, `\)`,
+		`[`, `\[`, `]`, `\]`, `{`, `\{`, `}`, `\}`, `^`, `\^`, `$`, `\$`, `|`, `\|`,
+	)
+	return replacer.Replace(s)
+}
+
+func buildSearchText(filename string, metadata map[string]any) string {
+	parts := []string{filename}
+	for _, v := range metadata {
+		parts = append(parts, fmt.Sprintf("%v", v))
+	}
+	return strings.Join(parts, " ")
+}
+
+// SearchImages runs query against the image metadata collection and returns
+// the matching page alongside the total match count, computed in one
+// round-trip via $facet.
+func (is *ImageStore) SearchImages(ctx context.Context, query *Query, limit, offset int64) ([]*ImageMeta, int64, error) {
+	match := query.Build()
+
+	dataPipeline := bson.A{
+		bson.M{"$sort": bson.M{"createdAt": -1}},
+	}
+	if offset > 0 {
+		dataPipeline = append(dataPipeline, bson.M{"$skip": offset})
+	}
+	if limit > 0 {
+		dataPipeline = append(dataPipeline, bson.M{"$limit": limit})
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$facet": bson.M{
+			"data":  dataPipeline,
+			"count": bson.A{bson.M{"$count": "total"}},
+		}},
+	}
+
+	cursor, err := is.metaCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search images: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Data  []*ImageMeta `bson:"data"`
+		Count []struct {
+			Total int64 `bson:"total"`
+		} `bson:"count"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode search results: %w", err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, fmt.Errorf("cursor error: %w", err)
+	}
+
+	var total int64
+	if len(result.Count) > 0 {
+		total = result.Count[0].Total
+	}
+
+	return result.Data, total, nil
+}
+
+// ensureIndexes creates the indexes SearchImages and the rest of ImageStore
+// rely on, if they don't already exist.
+func (is *ImageStore) ensureIndexes(ctx context.Context) error {
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "imageID", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "checksum", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "originalName", Value: "text"}, {Key: "searchText", Value: "text"}}},
+	}
+
+	if _, err := is.metaCollection.Indexes().CreateMany(ctx, models); err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+	return nil
+}
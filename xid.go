@@ -36,5 +36,9 @@ func GenerateSHA1(text string) string {
 
 // check xid is exists
 func CheckXidExists(collection string, xid string) bool {
-	return GetCollection(collection).FindOne(context.Background(), bson.M{"xid": xid}).Err() == nil
+	coll, err := GetCollection(collection)
+	if err != nil {
+		return false
+	}
+	return coll.FindOne(context.Background(), bson.M{"xid": xid}).Err() == nil
 }